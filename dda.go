@@ -0,0 +1,152 @@
+package main
+
+import "math"
+
+// This file replaces the original fixed-step ray marcher (which advanced
+// fDistanceToWall by a constant fStepSize) with a digital differential
+// analyzer (DDA) over a map's integer grid. The hand-built example world in
+// sector.go isn't a grid at all -- its sectors are arbitrary polygons, so it
+// still needs the portal BFS in renderSectors -- but a map loaded from a
+// .fps file (mapfile.go) is a flat, axis-aligned grid, and for that case DDA
+// gives an exact per-column wall distance in one marching pass instead of
+// walking sector edges, which matters once a map gets as large as 128x128.
+
+// ddaCast marches the ray from (px, py) in direction (dx, dy) across m's
+// grid one cell at a time, returning the ray parameter at which it reaches
+// the wall face it hits (for a unit-length (dx, dy), as renderGrid passes,
+// that is the straight-line distance from (px, py); it is not corrected for
+// fish-eye), whether the ray hit that face while stepping in Y, i.e. a face
+// running along the X axis (a "north/south" wall), so callers can vary
+// shading by side instead of the old corner dot-product test, and the map
+// character of the cell hit so callers can look up its texture.
+func ddaCast(m *Map, px, py, dx, dy float64) (dist float64, sideIsY bool, hitChar byte) {
+	mapX, mapY := int(px), int(py)
+	deltaDistX := math.Abs(1 / dx)
+	deltaDistY := math.Abs(1 / dy)
+
+	var stepX, stepY int
+	var sideDistX, sideDistY float64
+	if dx < 0 {
+		stepX = -1
+		sideDistX = (px - float64(mapX)) * deltaDistX
+	} else {
+		stepX = 1
+		sideDistX = (float64(mapX+1) - px) * deltaDistX
+	}
+	if dy < 0 {
+		stepY = -1
+		sideDistY = (py - float64(mapY)) * deltaDistY
+	} else {
+		stepY = 1
+		sideDistY = (float64(mapY+1) - py) * deltaDistY
+	}
+
+	for {
+		if sideDistX < sideDistY {
+			sideDistX += deltaDistX
+			mapX += stepX
+			sideIsY = false
+		} else {
+			sideDistY += deltaDistY
+			mapY += stepY
+			sideIsY = true
+		}
+		if mapX < 0 || mapX >= m.Width || mapY < 0 || mapY >= m.Height || m.Grid[mapY][mapX] == '#' {
+			break
+		}
+	}
+
+	if mapX < 0 || mapX >= m.Width || mapY < 0 || mapY >= m.Height {
+		hitChar = '#' // ray ran off the (walled) map edge; treat as a plain wall
+	} else {
+		hitChar = m.Grid[mapY][mapX]
+	}
+
+	if sideIsY {
+		dist = (float64(mapY) - py + float64(1-stepY)/2) / dy
+	} else {
+		dist = (float64(mapX) - px + float64(1-stepX)/2) / dx
+	}
+	return dist, sideIsY, hitChar
+}
+
+// renderGrid is the DDA-based counterpart to renderSectors for a map loaded
+// from a .fps file. Every grid sector shares the map's flat fMapFloor/
+// fMapCeil, so one wall distance per column is enough to know exactly how
+// much ceiling and floor that column still needs, same as the fixed-height
+// raycaster this replaces -- just with an exact distance instead of one
+// accurate to within fStepSize.
+func renderGrid(m *Map, nScreenWidth, nScreenHeight int) (ymin, ymax, zbuf []float64) {
+	ymin = make([]float64, nScreenWidth)
+	ymax = make([]float64, nScreenWidth)
+	zbuf = make([]float64, nScreenWidth)
+
+	eyeZ := fMapFloor + fPlayerHeightOffset + fPlayerEyeZ
+
+	for x := 0; x < nScreenWidth; x++ {
+		rayA := fPlayerA - fHFOV/2.0 + (float64(x)+0.5)/float64(nScreenWidth)*fHFOV
+		dx, dy := math.Sin(rayA), math.Cos(rayA)
+		dist, sideIsY, hitChar := ddaCast(m, fPlayerX, fPlayerY, dx, dy)
+		if dist > fDepth {
+			dist = fDepth
+		}
+		zbuf[x] = dist
+
+		top := clamp(screenYFor(nScreenHeight, fMapCeil, dist, eyeZ), 0, float64(nScreenHeight))
+		bot := clamp(screenYFor(nScreenHeight, fMapFloor, dist, eyeZ), 0, float64(nScreenHeight))
+
+		tex := m.Walls[hitChar]
+		if tex == nil {
+			fillSpan(x, top, bot, wallShadeRune(dist, sideIsY))
+		} else {
+			// wallX is where along the wall face the ray landed, per Lode's
+			// tutorial convention: on an X-side wall it's carried by Y, on a
+			// Y-side wall it's carried by X.
+			var wallX float64
+			if sideIsY {
+				wallX = fPlayerX + dist*dx
+			} else {
+				wallX = fPlayerY + dist*dy
+			}
+			u := wallX - math.Floor(wallX)
+			style := dimStyle(mazeStyle, dist)
+			for y := int(top); y < int(bot); y++ {
+				v := (float64(y) - top) / (bot - top)
+				screen.SetContent(x, y, tex.at(u, v), nil, style)
+			}
+		}
+		ymin[x], ymax[x] = top, bot
+	}
+	return ymin, ymax, zbuf
+}
+
+// gridFloorSample inverts screenYFor to find the world floor coordinate
+// under screen pixel (x, y), for the caller's floor-fill loop to texture
+// instead of drawing the flat ASCII pattern. Assumes y is below the
+// horizon (nScreenHeight/2), i.e. was already past ymax[x].
+func gridFloorSample(x, y, nScreenWidth, nScreenHeight int) (u, v float64) {
+	eyeZ := fMapFloor + fPlayerHeightOffset + fPlayerEyeZ
+	d := (fMapFloor - eyeZ) * (float64(nScreenHeight) / fVFOV) / (float64(nScreenHeight)/2.0 - float64(y))
+
+	rayA := fPlayerA - fHFOV/2.0 + (float64(x)+0.5)/float64(nScreenWidth)*fHFOV
+	dx, dy := math.Sin(rayA), math.Cos(rayA)
+
+	wx := fPlayerX + d*dx
+	wy := fPlayerY + d*dy
+	return wx - math.Floor(wx), wy - math.Floor(wy)
+}
+
+// gridCeilingSample is gridFloorSample's analogue for the sky-fill loop,
+// projecting against fMapCeil instead of fMapFloor. Assumes y is above the
+// horizon (nScreenHeight/2), i.e. was already above ymin[x].
+func gridCeilingSample(x, y, nScreenWidth, nScreenHeight int) (u, v float64) {
+	eyeZ := fMapFloor + fPlayerHeightOffset + fPlayerEyeZ
+	d := (fMapCeil - eyeZ) * (float64(nScreenHeight) / fVFOV) / (float64(nScreenHeight)/2.0 - float64(y))
+
+	rayA := fPlayerA - fHFOV/2.0 + (float64(x)+0.5)/float64(nScreenWidth)*fHFOV
+	dx, dy := math.Sin(rayA), math.Cos(rayA)
+
+	wx := fPlayerX + d*dx
+	wy := fPlayerY + d*dy
+	return wx - math.Floor(wx), wy - math.Floor(wy)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// This file introduces entities: billboarded sprites (enemies, torches,
+// pickups) layered on top of the sector renderer's wall/floor/ceiling pass.
+// Entities are drawn back-to-front and clipped column-by-column against the
+// z-buffer renderSectors (or renderGrid) produces, so a closer wall correctly
+// occludes them.
+
+// SpriteMode selects how an entity's flat sprite is oriented relative to the
+// player.
+type SpriteMode int
+
+const (
+	// SpriteFacing always rotates the sprite to face the camera, like a
+	// classic billboard (torches, pickups, most enemies).
+	SpriteFacing SpriteMode = iota
+	// SpritePaper draws the sprite as an infinitely thin plane fixed along
+	// its own angle (FF_PAPERSPRITE): it occupies exactly the screen columns
+	// where a ray from the player crosses that oriented segment, so it
+	// foreshortens properly when viewed edge-on.
+	SpritePaper
+)
+
+// An entity is a world object rendered as a 2D rune sprite. Z is the height
+// above the floor of the sector it's standing in, not an absolute world
+// height, so an entity placed near a step still sits flush with the ground
+// on either side of it. angle only matters in SpritePaper mode, where it's
+// the facing of the sprite's plane.
+type entity struct {
+	X, Y, Z float64
+	angle   float64
+	sprite  [][]rune
+	mode    SpriteMode
+}
+
+var entities []*entity
+
+// fSpriteAlpha marks a transparent sprite pixel, following the same
+// convention as the moon sprite in main.go.
+const fSpriteAlpha = '@'
+
+// fSpriteSize is the world-space width and height of a sprite, in the same
+// units as sector floor/ceiling heights.
+const fSpriteSize = 1.0
+
+// AddEntity registers a new entity at (x, y) using the given sprite and
+// rendering mode, and returns it so callers can adjust Z/angle afterwards.
+func AddEntity(x, y float64, sprite [][]rune, mode SpriteMode) *entity {
+	e := &entity{X: x, Y: y, sprite: sprite, mode: mode}
+	entities = append(entities, e)
+	return e
+}
+
+// renderEntities draws every entity back-to-front so nearer ones correctly
+// overdraw farther ones, clipping each against zbuf (the wall distance
+// renderSectors or renderGrid left behind per column).
+func renderEntities(world []sector, playerSector int, nScreenWidth, nScreenHeight int, zbuf []float64) {
+	eyeZ := world[playerSector].floorAt(fPlayerX, fPlayerY) + fPlayerHeightOffset + fPlayerEyeZ
+
+	order := make([]int, len(entities))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return entityDistSq(entities[order[i]]) > entityDistSq(entities[order[j]])
+	})
+
+	for _, i := range order {
+		e := entities[i]
+		floor := 0.0
+		if sec := findSector(world, playerSector, e.X, e.Y); sec >= 0 {
+			floor = world[sec].floorAt(e.X, e.Y)
+		}
+		switch e.mode {
+		case SpritePaper:
+			renderPaperSprite(e, floor, nScreenWidth, nScreenHeight, eyeZ, zbuf)
+		default:
+			renderFacingSprite(e, floor, nScreenWidth, nScreenHeight, eyeZ, zbuf)
+		}
+	}
+}
+
+// renderFacingSprite draws e as a billboard: always square-on to the camera,
+// scaled by its perpendicular (fish-eye-corrected) distance.
+func renderFacingSprite(e *entity, floor float64, nScreenWidth, nScreenHeight int, eyeZ float64, zbuf []float64) {
+	relA := relativeAngle(vertex{e.X, e.Y})
+	dist := math.Hypot(e.X-fPlayerX, e.Y-fPlayerY)
+	dyView := dist * math.Cos(relA)
+	if dyView < 0.1 || math.Abs(relA) > fHFOV/2+0.5 {
+		return // behind the near plane, or far enough outside the view cone to skip
+	}
+
+	z := floor + e.Z
+	top := screenYFor(nScreenHeight, z+fSpriteSize, dyView, eyeZ)
+	bot := screenYFor(nScreenHeight, z, dyView, eyeZ)
+	height := bot - top
+	if height <= 0 {
+		return
+	}
+	centerCol := (relA + fHFOV/2) / fHFOV * float64(nScreenWidth)
+	halfWidth := height / 2 // treat the sprite as square in screen space
+
+	spriteH, spriteW := len(e.sprite), len(e.sprite[0])
+	for x := int(centerCol - halfWidth); x <= int(centerCol+halfWidth); x++ {
+		if x < 0 || x >= nScreenWidth || dyView >= zbuf[x] {
+			continue
+		}
+		u := (float64(x) - (centerCol - halfWidth)) / (2 * halfWidth)
+		su := clampInt(int(u*float64(spriteW)), 0, spriteW-1)
+		drawSpriteColumn(x, e.sprite, su, spriteH, top, bot, nScreenHeight)
+	}
+}
+
+// renderPaperSprite draws e as a thin plane fixed at e.angle: for each screen
+// column, a ray is cast from the player and tested against the sprite's
+// segment exactly as renderSectors tests wall edges, giving correct
+// foreshortening when viewed edge-on.
+func renderPaperSprite(e *entity, floor float64, nScreenWidth, nScreenHeight int, eyeZ float64, zbuf []float64) {
+	halfW := fSpriteSize / 2
+	dirX, dirY := math.Sin(e.angle), math.Cos(e.angle)
+	v1 := vertex{e.X - dirX*halfW, e.Y - dirY*halfW}
+	v2 := vertex{e.X + dirX*halfW, e.Y + dirY*halfW}
+
+	z := floor + e.Z
+	spriteH, spriteW := len(e.sprite), len(e.sprite[0])
+
+	for x := 0; x < nScreenWidth; x++ {
+		rayA := fPlayerA - fHFOV/2.0 + (float64(x)+0.5)/float64(nScreenWidth)*fHFOV
+		eyeX, eyeY := math.Sin(rayA), math.Cos(rayA)
+		dist, hit := intersectRay(fPlayerX, fPlayerY, eyeX, eyeY, v1, v2)
+		if !hit || dist >= zbuf[x] {
+			continue
+		}
+		wx, wy := fPlayerX+eyeX*dist, fPlayerY+eyeY*dist
+		u := math.Hypot(wx-v1.x, wy-v1.y) / fSpriteSize
+
+		top := screenYFor(nScreenHeight, z+fSpriteSize, dist, eyeZ)
+		bot := screenYFor(nScreenHeight, z, dist, eyeZ)
+		if bot <= top {
+			continue
+		}
+		su := clampInt(int(u*float64(spriteW)), 0, spriteW-1)
+		drawSpriteColumn(x, e.sprite, su, spriteH, top, bot, nScreenHeight)
+	}
+}
+
+// drawSpriteColumn samples sprite column su down the screen rows spanned by
+// [top, bot), skipping the transparent alpha rune.
+func drawSpriteColumn(x int, sprite [][]rune, su, spriteH int, top, bot float64, nScreenHeight int) {
+	height := bot - top
+	for y := int(top); y < int(bot); y++ {
+		if y < 0 || y >= nScreenHeight {
+			continue
+		}
+		v := (float64(y) - top) / height
+		sv := clampInt(int(v*float64(spriteH)), 0, spriteH-1)
+		r := sprite[sv][su]
+		if r == fSpriteAlpha {
+			continue
+		}
+		screen.SetContent(x, y, r, nil, mazeStyle)
+	}
+}
+
+func entityDistSq(e *entity) float64 {
+	dx, dy := e.X-fPlayerX, e.Y-fPlayerY
+	return dx*dx + dy*dy
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// torchSprite and enemySprite seed the demo world; '@' is the transparent
+// alpha rune, same convention as the moon sprite in main.go.
+var torchSprite = [][]rune{
+	[]rune("@▓▓@"),
+	[]rune("@██@"),
+	[]rune("▓██▓"),
+	[]rune("@││@"),
+}
+
+var enemySprite = [][]rune{
+	[]rune("@@██@@"),
+	[]rune("@████@"),
+	[]rune("██░░██"),
+	[]rune("@████@"),
+	[]rune("@@██@@"),
+}
@@ -4,11 +4,11 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"math"
 	"math/rand"
 	"os"
-	"sort"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
@@ -24,13 +24,13 @@ const debug = false // display coordinates and other info at top of screen
 const pi = 3.14159
 const tau = 2 * pi
 
-const nMapWidth = 16 // world dimensions
-const nMapHeight = 16
-
-var fPlayerX = 14.4              // player x position
-var fPlayerY = 14.7              // player y position
+var fPlayerX = 2.0               // player x position
+var fPlayerY = 2.0               // player y position
 var fPlayerA = pi                // player angle
-const fFOV = pi / 4              // field of view
+var nPlayerSector = 0            // index of the sector the player is standing in
+var fPlayerEyeZ = fEyeHeight     // current eye offset above the floor (no control lowers it for ducking)
+var fPlayerHeightOffset = 0.0    // extra height above the current floor, eased out by gravity (step-ups)
+var fPlayerVelZ = 0.0            // vertical velocity driving fPlayerHeightOffset
 const fDepth = 16.0              // maximum rendering distance
 const fSpeed = 9.0               // walking speed
 const fTurnSpeed = fSpeed * 0.75 // added convenience constant
@@ -67,9 +67,43 @@ var moon = [6][]rune{
 
 const nMoonWidth = 12
 const nMoonHeight = 6
-const fMoonA = pi // moon's angle in the sky
+
+var fMoonA = pi         // moon's angle in the sky
+var fStarDensity = 0.02 // chance a given sky cell is a star
 
 func main() {
+	mapPath := flag.String("map", "", "path to a .fps map file (falls back to the built-in level if omitted)")
+	flag.Parse()
+
+	// world is the sector graph the portal renderer walks each frame. It
+	// either comes from a map file or, absent one, the hand-built example
+	// world below. loadedMap is non-nil only in the former case: its flat
+	// grid lets the frame loop take the faster DDA path (dda.go) instead of
+	// the general portal BFS.
+	var world []sector
+	var loadedMap *Map
+	if *mapPath != "" {
+		m, err := LoadMap(*mapPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		loadedMap = m
+		world = m.World
+		fPlayerX, fPlayerY, fPlayerA = m.PlayerX, m.PlayerY, m.PlayerA
+		fMoonA, fStarDensity = m.MoonAngle, m.StarDensity
+		for _, spawn := range m.EntitySpawns {
+			e := AddEntity(spawn.X, spawn.Y, m.Sprites[spawn.Sprite], spawn.Mode)
+			e.angle = spawn.Angle
+		}
+	} else {
+		world = newExampleWorld()
+		// A torch planted by the entry doorway and an enemy standing further
+		// back, to exercise both sprite modes against the new renderer.
+		AddEntity(6.5, 3.5, torchSprite, SpriteFacing)
+		AddEntity(8, 8, enemySprite, SpritePaper)
+	}
+	nPlayerSector = findSector(world, nPlayerSector, fPlayerX, fPlayerY)
 
 	// create screen buffer
 	if screen, err = tcell.NewScreen(); err != nil {
@@ -92,11 +126,11 @@ func main() {
 	// cylindrical projection and a randomly-generated starfield. The projection
 	// uses the field of view to calculate an apparent radius and then
 	// circumference for the sky
-	nSkyHeight := nScreenHeight / 2                    // horizon to top
-	fSkyApparentRadius := float64(nScreenWidth) / fFOV // r = s/θ, where θ is the field of view
-	nSkyCircumference := int(tau * fSkyApparentRadius) // C = 2πr
-	nMoonStartX := int(fSkyApparentRadius * fMoonA)    // s = rθ, the x offset of the moon in the sky
-	nMoonStartY := 1                                   // close to the top so visible from most places in the maze
+	nSkyHeight := nScreenHeight / 2                     // horizon to top
+	fSkyApparentRadius := float64(nScreenWidth) / fHFOV // r = s/θ, where θ is the field of view
+	nSkyCircumference := int(tau * fSkyApparentRadius)  // C = 2πr
+	nMoonStartX := int(fSkyApparentRadius * fMoonA)     // s = rθ, the x offset of the moon in the sky
+	nMoonStartY := 1                                    // close to the top so visible from most places in the maze
 	// make the sky:
 	sky := make([][]rune, nSkyCircumference)
 	moonCoords := make([][2]int, 0)
@@ -121,7 +155,7 @@ func main() {
 			case bIsMoon:
 				sky[x][y] = rMoonShade
 				moonCoords = append(moonCoords, [2]int{x, y})
-			case rand.Float64() < 0.02: // a scattering of stars
+			case rand.Float64() < fStarDensity: // a scattering of stars
 				sky[x][y] = '.'
 			default:
 				sky[x][y] = ' ' // the ebon void
@@ -129,26 +163,6 @@ func main() {
 		}
 	}
 
-	// Back to translating JavidX9's original code: create map of world where
-	// '#' == wall, '.' == space
-	const worldMap = "" +
-		"#########......." +
-		"#..............." +
-		"#.......########" +
-		"#..............#" +
-		"#......##......#" +
-		"#......##......#" +
-		"#..............#" +
-		"###............#" +
-		"##.............#" +
-		"#......####..###" +
-		"#......#.......#" +
-		"#......#.......#" +
-		"#..............#" +
-		"#......#########" +
-		"#..............." +
-		"################"
-
 	// start ticker and timing
 	ticker := time.NewTicker(tick)
 	tp1 := time.Now()
@@ -185,160 +199,92 @@ func main() {
 				fPlayerA = angle - tau*math.Floor(angle/tau)
 			case event.Key() == tcell.KeyUp:
 				// Forward movement and collision
-				fPlayerX += math.Sin(fPlayerA) * fSpeed * tick.Seconds()
-				fPlayerY += math.Cos(fPlayerA) * fSpeed * tick.Seconds()
-				nMapIndex := int(fPlayerX)*nMapWidth + int(fPlayerY)
-				if nMapIndex < 0 || nMapIndex >= len(worldMap) || // we add extra check for out of map bounds
-					worldMap[nMapIndex] == '#' {
-					// collision; seems odd to first move into the wall above,
-					// then back out here, but that's how the original does it
-					fPlayerX -= math.Sin(fPlayerA) * fSpeed * tick.Seconds()
-					fPlayerY -= math.Cos(fPlayerA) * fSpeed * tick.Seconds()
-				}
+				movePlayer(world, math.Sin(fPlayerA)*fSpeed*tick.Seconds(), math.Cos(fPlayerA)*fSpeed*tick.Seconds())
 			case event.Key() == tcell.KeyDown:
 				// Backward movement and collision
-				fPlayerX -= math.Sin(fPlayerA) * fSpeed * tick.Seconds()
-				fPlayerY -= math.Cos(fPlayerA) * fSpeed * tick.Seconds()
-				nMapIndex := int(fPlayerX)*nMapWidth + int(fPlayerY)
-				if nMapIndex < 0 || nMapIndex >= len(worldMap) || // we add extra check for out of map bounds
-					worldMap[nMapIndex] == '#' {
-					fPlayerX += math.Sin(fPlayerA) * fSpeed * tick.Seconds()
-					fPlayerY += math.Cos(fPlayerA) * fSpeed * tick.Seconds()
-				}
+				movePlayer(world, -math.Sin(fPlayerA)*fSpeed*tick.Seconds(), -math.Cos(fPlayerA)*fSpeed*tick.Seconds())
 			}
 		}
 
-		for x := 0; x < nScreenWidth; x++ {
-			// Loop over text columns
-
-			// Calculate the projected ray angle into the world
-			fRayAngle := (fPlayerA - fFOV/2.0) + (float64(x) / float64(nScreenWidth) * fFOV)
-
-			// Find distance to wall
-			fStepSize := 0.1 // for ray casting, decrease to increase resolution
-			fDistanceToWall := 0.0
-
-			bHitWall := false  // set when ray hits a wall block
-			bBoundary := false // set when ray hits boundary between two wall blocks
-
-			fEyeX := math.Sin(fRayAngle) // unit vector for ray
-			fEyeY := math.Cos(fRayAngle)
-
-			// Cast ray from player, along ray angle, testing for entry into a
-			// wall block at intervals determined by step size. As JavidX9
-			// noted, this is only the most efficient algorithm if you happen to
-			// be close to a wall
-			for !bHitWall && fDistanceToWall < fDepth {
-				fDistanceToWall += fStepSize
-				nTestX := int(fPlayerX + fEyeX*fDistanceToWall)
-				nTestY := int(fPlayerY + fEyeY*fDistanceToWall)
-
-				// Test for a step into a wall
-				if nTestX < 0 || nTestX >= nMapWidth || nTestY < 0 || nTestY >= nMapHeight {
-					bHitWall = true
-					fDistanceToWall = fDepth
-				} else if worldMap[nTestX*nMapWidth+nTestY] == '#' {
-					bHitWall = true // folks, we hit a wall
-
-					// And now a tricky part (present in the original), where we
-					// check whether the ray we cast is "close" to a corner of
-					// the wall block we hit, and, if it is, we'll shade it
-					// differently to mark block boundaries. Here, "close" is
-					// defined as the dot product of the cast ray and the ray
-					// from the block corner to the player fitting within a
-					// certain tolerance
-
-					// As in the original, we'll store the distance from the
-					// corner to the player, d, and the dot product of the
-					// corner ray with the casting ray, dot, as a slice of
-					// pairs: [][2]float64{d, dot}
-					p := make([][2]float64, 0)
-					for tx := 0; tx < 2; tx++ {
-						for ty := 0; ty < 2; ty++ {
-							vy := float64(nTestY) + float64(ty) - fPlayerY
-							vx := float64(nTestX) + float64(tx) - fPlayerX
-							d := math.Sqrt(vx*vx + vy*vy)
-							dot := (fEyeX * vx / d) + (fEyeY * vy / d)
-							p = append(p, [2]float64{d, dot})
-						}
-					}
-
-					// Sort pairs from closest to farthest
-					sort.Slice(p, func(i, j int) bool { return p[i][0] < p[j][0] })
-
-					fBound := 0.01 // tolerance to be considered a corner hit
+		// Gravity eases fPlayerHeightOffset back to the current sector's
+		// floor after a step-up/step-down, instead of snapping instantly.
+		if fPlayerHeightOffset > 0 {
+			fPlayerVelZ -= fGravity * tick.Seconds()
+			fPlayerHeightOffset += fPlayerVelZ * tick.Seconds()
+			if fPlayerHeightOffset <= 0 {
+				fPlayerHeightOffset = 0
+				fPlayerVelZ = 0
+			}
+		} else if fPlayerHeightOffset < 0 {
+			// After a step-up the eye starts below its resting height and
+			// climbs back at the same rate.
+			fPlayerVelZ += fGravity * tick.Seconds()
+			fPlayerHeightOffset += fPlayerVelZ * tick.Seconds()
+			if fPlayerHeightOffset >= 0 {
+				fPlayerHeightOffset = 0
+				fPlayerVelZ = 0
+			}
+		}
 
-					// Check the first two/three corners: we'll never see all
-					// four. As JavidX9 notes in the video, this does lead
-					// occasionally to viewing corners that should be obscured
-					// by a block face. We can fix this in a later revision
-					switch {
-					case math.Acos(p[0][1]) < fBound:
-						bBoundary = p[0][0] < fDistanceToWall
-					case math.Acos(p[1][1]) < fBound:
-						bBoundary = p[1][0] < fDistanceToWall
-					case math.Acos(p[2][1]) < fBound:
-						bBoundary = p[2][0] < fDistanceToWall
+		// Render the visible sectors (or, for a loaded map, the DDA grid),
+		// then fill whatever each column left open with sky above and floor
+		// pattern below, as the old raycaster did above nCeiling/below
+		// nFloor; a loaded map with ceiling/floor textures gets those instead.
+		var ymin, ymax, zbuf []float64
+		if loadedMap != nil {
+			ymin, ymax, zbuf = renderGrid(loadedMap, nScreenWidth, nScreenHeight)
+		} else {
+			ymin, ymax, zbuf = renderSectors(world, nPlayerSector, nScreenWidth, nScreenHeight)
+		}
+		for x := 0; x < nScreenWidth; x++ {
+			for y := 0; y < int(ymin[x]); y++ {
+				if loadedMap != nil && loadedMap.CeilTexture != nil {
+					u, v := gridCeilingSample(x, y, nScreenWidth, nScreenHeight)
+					b := float64(y) / (float64(nScreenHeight) / 2.0) // 0 at the top (close), 1 at the horizon (far)
+					dist := b * fDepth
+					screen.SetContent(x, y, loadedMap.CeilTexture.at(u, v), nil, dimStyle(mazeStyle, dist))
+					continue
+				}
+				// Sky!
+				angle := fPlayerA - pi/8
+				angle = angle - tau*math.Floor(angle/tau)
+				nPlayerAOffset := (x + int(fSkyApparentRadius*angle)) % nSkyCircumference
+				style := skyStyle // for stars
+				for _, coord := range moonCoords {
+					if [2]int{nPlayerAOffset, y} == coord {
+						style = moonStyle // moon!
+						break
 					}
 				}
+				screen.SetContent(x, y, sky[nPlayerAOffset][y], nil, style)
 			}
-
-			// Calculate distance to ceiling (which we made a sky) and floor
-			nCeiling := float64(nScreenHeight)/2.0 - float64(nScreenHeight)/fDistanceToWall
-			nFloor := float64(nScreenHeight) - nCeiling
-
-			var rShade rune // nShade in the original
-			switch {
-			case bBoundary == true:
-				rShade = ' ' // black out wall block boundary
-			case fDistanceToWall <= fDepth/3.0: // close, bright
-				rShade = '█'
-			case fDistanceToWall <= fDepth/2.0:
-				rShade = '▓'
-			case fDistanceToWall <= fDepth/1.1: // far, dark
-				rShade = '░'
-			default:
-				rShade = ' ' // too far away, black
-			}
-
-			// Draw the screen!
-			for y := 0; y < nScreenHeight; y++ {
-				fY := float64(y)
+			for y := int(ymax[x]); y < nScreenHeight; y++ {
+				if loadedMap != nil && loadedMap.FloorTexture != nil {
+					u, v := gridFloorSample(x, y, nScreenWidth, nScreenHeight)
+					b := 1.0 - (float64(y)-float64(nScreenHeight)/2.0)/(float64(nScreenHeight)/2.0)
+					dist := b * fDepth // b is 0 at the bottom (close) and 1 at the horizon (far)
+					screen.SetContent(x, y, loadedMap.FloorTexture.at(u, v), nil, dimStyle(mazeStyle, dist))
+					continue
+				}
+				// Floor, shaded by distance from player
+				var rShade rune
+				b := 1.0 - (float64(y)-float64(nScreenHeight)/2.0)/(float64(nScreenHeight)/2.0)
 				switch {
-				case fY <= nCeiling:
-					// Sky!
-					angle := fPlayerA - pi/8
-					angle = angle - tau*math.Floor(angle/tau)
-					nPlayerAOffset := (x + int(fSkyApparentRadius*angle)) % nSkyCircumference
-					style := skyStyle // for stars
-					for _, coord := range moonCoords {
-						if [2]int{nPlayerAOffset, y} == coord {
-							style = moonStyle // moon!
-							break
-						}
-					}
-					screen.SetContent(x, y, sky[nPlayerAOffset][y], nil, style)
-				case fY > nCeiling && fY <= nFloor:
-					screen.SetContent(x, y, rShade, nil, mazeStyle)
+				case b < 0.25:
+					rShade = '#'
+				case b < 0.5:
+					rShade = 'x'
+				case b < 0.75:
+					rShade = '.'
+				case b < 0.9:
+					rShade = '-'
 				default:
-					// Floor, shaded by distance from player
-					b := 1.0 - (float64(y)-float64(nScreenHeight)/2.0)/(float64(nScreenHeight)/2.0)
-					switch {
-					case b < 0.25:
-						rShade = '#'
-					case b < 0.5:
-						rShade = 'x'
-					case b < 0.75:
-						rShade = '.'
-					case b < 0.9:
-						rShade = '-'
-					default:
-						rShade = ' '
-					}
-					screen.SetContent(x, y, rShade, nil, mazeStyle)
+					rShade = ' '
 				}
+				screen.SetContent(x, y, rShade, nil, mazeStyle)
 			}
 		}
+		renderEntities(world, nPlayerSector, nScreenWidth, nScreenHeight, zbuf)
 		if debug {
 			// Display stats
 			stats := fmt.Sprintf("X=%3.2f, Y=%3.2f, A=%3.2f, FPS=%3.2f, W=%v, C=%v, R=%v", fPlayerX, fPlayerY, fPlayerA, 1.0/fElapsedTime, nScreenWidth, nSkyCircumference, fSkyApparentRadius)
@@ -0,0 +1,482 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// This file introduces a plain-text map format so levels no longer have to
+// be a hard-coded worldMap constant. A map file is a handful of `[section]`
+// blocks:
+//
+//	[map]
+//	################
+//	#..............#
+//	################
+//
+//	[player]
+//	x = 2.0
+//	y = 1.5
+//	angle = 3.14159
+//
+//	[sky]
+//	moon_angle = 3.14159
+//	star_density = 0.02
+//
+//	[sprites]
+//	torch
+//	@▓▓@
+//	@██@
+//	▓██▓
+//	@││@
+//
+//	enemy
+//	@@██@@
+//	@████@
+//	██░░██
+//	@████@
+//	@@██@@
+//
+//	[entities]
+//	torch 6.5 1.5 facing
+//	enemy 8 1.5 paper 3.14159
+//
+// [map] rows are addressed (x, y) with x as the column and y as the row,
+// matching fPlayerX/fPlayerY. A cell is either '#' (wall) or '.' (open
+// floor); one sector is generated per open cell, unit-square in size, joined
+// by portals to its open 4-neighbors. This mirrors newExampleWorld's
+// hand-built sectors but lets a level be arbitrarily sized instead of a
+// fixed 16x16 grid.
+//
+// There's no go.mod in this tree to hang a separate package off of, so,
+// consistent with sector.go and entity.go, this stays part of package main.
+
+// entitySpawn is one row of the [entities] section: a named sprite placed at
+// a world position, resolved against Map.Sprites once parsing is complete.
+type entitySpawn struct {
+	Sprite string
+	X, Y   float64
+	Mode   SpriteMode
+	Angle  float64
+}
+
+// Map is everything LoadMap extracts from a .fps map file: the sector graph
+// the renderer walks, where the player starts, sky dressing, and the
+// entities to seed the world with.
+type Map struct {
+	Width, Height int
+	World         []sector
+	Grid          []string // raw rows, kept for the DDA fast path in dda.go
+
+	PlayerX, PlayerY, PlayerA float64
+
+	MoonAngle   float64
+	StarDensity float64
+
+	Sprites      map[string][][]rune
+	EntitySpawns []entitySpawn
+
+	Walls        TextureSet // wall map character -> texture, e.g. '#' -> brick
+	FloorTexture *texture
+	CeilTexture  *texture
+}
+
+// fMapFloor/fMapCeil are the floor/ceiling heights given to every sector a
+// grid map generates; a flat grid map has no notion of varying room
+// heights, unlike the hand-authored example world in sector.go.
+const fMapFloor = 0.0
+const fMapCeil = 3.0
+
+// LoadMap reads and parses a .fps map file at path, returning a validated
+// Map or a descriptive error.
+func LoadMap(path string) (*Map, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("mapfile: %w", err)
+	}
+	defer f.Close()
+
+	sections, err := splitSections(f)
+	if err != nil {
+		return nil, fmt.Errorf("mapfile: %s: %w", path, err)
+	}
+
+	m := &Map{
+		PlayerA:     pi,
+		StarDensity: 0.02,
+		Sprites:     map[string][][]rune{},
+	}
+
+	grid, ok := sections["map"]
+	if !ok || len(grid) == 0 {
+		return nil, fmt.Errorf("mapfile: %s: missing or unclosed [map] section", path)
+	}
+	if err := m.loadGrid(grid); err != nil {
+		return nil, fmt.Errorf("mapfile: %s: %w", path, err)
+	}
+
+	if player, ok := sections["player"]; ok {
+		if err := m.loadPlayer(player); err != nil {
+			return nil, fmt.Errorf("mapfile: %s: %w", path, err)
+		}
+	}
+	if !m.inBoundsAndOpen(m.PlayerX, m.PlayerY) {
+		return nil, fmt.Errorf("mapfile: %s: player spawn (%.2f, %.2f) is out of bounds or inside a wall", path, m.PlayerX, m.PlayerY)
+	}
+
+	if sky, ok := sections["sky"]; ok {
+		if err := m.loadSky(sky); err != nil {
+			return nil, fmt.Errorf("mapfile: %s: %w", path, err)
+		}
+	}
+
+	if sprites, ok := sections["sprites"]; ok {
+		if err := m.loadSprites(sprites); err != nil {
+			return nil, fmt.Errorf("mapfile: %s: %w", path, err)
+		}
+	}
+
+	if entitiesSec, ok := sections["entities"]; ok {
+		if err := m.loadEntities(entitiesSec); err != nil {
+			return nil, fmt.Errorf("mapfile: %s: %w", path, err)
+		}
+	}
+
+	if textures, ok := sections["textures"]; ok {
+		if err := m.loadTextures(textures, filepath.Dir(path)); err != nil {
+			return nil, fmt.Errorf("mapfile: %s: %w", path, err)
+		}
+	}
+
+	return m, nil
+}
+
+// splitSections groups a map file's lines by [section] header, preserving
+// blank lines (significant to the [sprites] parser) but dropping the header
+// lines themselves. There is no comment syntax: every other line is kept,
+// with only its trailing whitespace trimmed.
+func splitSections(f *os.File) (map[string][]string, error) {
+	sections := map[string][]string{}
+	var current string
+	haveSection := false
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			current = strings.ToLower(strings.TrimSpace(trimmed[1 : len(trimmed)-1]))
+			haveSection = true
+			if _, ok := sections[current]; !ok {
+				sections[current] = nil
+			}
+			continue
+		}
+		if !haveSection {
+			if trimmed != "" {
+				return nil, fmt.Errorf("content %q before any [section] header", trimmed)
+			}
+			continue
+		}
+		sections[current] = append(sections[current], line)
+	}
+	return sections, scanner.Err()
+}
+
+// loadGrid turns [map]'s text rows into one sector per open ('.') cell,
+// joined by portals to its open 4-neighbors, and rejects ragged rows or a
+// border that isn't fully walled off (an "unclosed" map).
+func (m *Map) loadGrid(rows []string) error {
+	for len(rows) > 0 && strings.TrimSpace(rows[len(rows)-1]) == "" {
+		rows = rows[:len(rows)-1] // drop the blank line separating [map] from the next section
+	}
+
+	width := 0
+	for _, r := range rows {
+		if len(r) > width {
+			width = len(r)
+		}
+	}
+	height := len(rows)
+	for y, r := range rows {
+		if len(r) != width {
+			return fmt.Errorf("map row %d has width %d, expected %d (ragged/unclosed map)", y, len(r), width)
+		}
+	}
+
+	cellOpen := func(x, y int) bool {
+		if x < 0 || x >= width || y < 0 || y >= height {
+			return false
+		}
+		return rows[y][x] == '.'
+	}
+
+	for x := 0; x < width; x++ {
+		for _, y := range []int{0, height - 1} {
+			if cellOpen(x, y) {
+				return fmt.Errorf("map is unclosed: open cell on the border at (%d, %d)", x, y)
+			}
+		}
+	}
+	for y := 0; y < height; y++ {
+		for _, x := range []int{0, width - 1} {
+			if cellOpen(x, y) {
+				return fmt.Errorf("map is unclosed: open cell on the border at (%d, %d)", x, y)
+			}
+		}
+	}
+
+	secIndex := make([][]int, width)
+	for x := range secIndex {
+		secIndex[x] = make([]int, height)
+		for y := range secIndex[x] {
+			secIndex[x][y] = -1
+		}
+	}
+
+	var world []sector
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if !cellOpen(x, y) {
+				continue
+			}
+			secIndex[x][y] = len(world)
+			world = append(world, sector{
+				vertices: []vertex{
+					{float64(x), float64(y)},
+					{float64(x + 1), float64(y)},
+					{float64(x + 1), float64(y + 1)},
+					{float64(x), float64(y + 1)},
+				},
+				floor: fMapFloor,
+				ceil:  fMapCeil,
+			})
+		}
+	}
+
+	// Second pass: neighbors need every sector to already have an index,
+	// since they can reference cells visited later in the scan above.
+	dirs := [4][2]int{{0, -1}, {1, 0}, {0, 1}, {-1, 0}} // matches the edge order above: N, E, S, W
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			idx := secIndex[x][y]
+			if idx < 0 {
+				continue
+			}
+			neighbors := make([]neighbor, 4)
+			for i, d := range dirs {
+				nx, ny := x+d[0], y+d[1]
+				to := -1
+				if cellOpen(nx, ny) {
+					to = secIndex[nx][ny]
+				}
+				neighbors[i] = neighbor{to: to}
+			}
+			world[idx].neighbors = neighbors
+		}
+	}
+
+	m.Width, m.Height, m.World, m.Grid = width, height, world, rows
+	return nil
+}
+
+func (m *Map) inBoundsAndOpen(x, y float64) bool {
+	cx, cy := int(x), int(y)
+	if cx < 0 || cx >= m.Width || cy < 0 || cy >= m.Height {
+		return false
+	}
+	return findSector(m.World, 0, x, y) >= 0
+}
+
+// loadPlayer reads `key = value` pairs for the player's spawn position and
+// facing angle.
+func (m *Map) loadPlayer(lines []string) error {
+	kv, err := parseKV(lines)
+	if err != nil {
+		return fmt.Errorf("[player]: %w", err)
+	}
+	for key, val := range kv {
+		f, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return fmt.Errorf("[player]: %s = %q is not a number", key, val)
+		}
+		switch key {
+		case "x":
+			m.PlayerX = f
+		case "y":
+			m.PlayerY = f
+		case "angle":
+			m.PlayerA = f
+		default:
+			return fmt.Errorf("[player]: unknown key %q", key)
+		}
+	}
+	return nil
+}
+
+// loadSky reads `key = value` pairs controlling the moon's position in the
+// sky and the density of the generated starfield.
+func (m *Map) loadSky(lines []string) error {
+	kv, err := parseKV(lines)
+	if err != nil {
+		return fmt.Errorf("[sky]: %w", err)
+	}
+	for key, val := range kv {
+		f, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return fmt.Errorf("[sky]: %s = %q is not a number", key, val)
+		}
+		switch key {
+		case "moon_angle":
+			m.MoonAngle = f
+		case "star_density":
+			m.StarDensity = f
+		default:
+			return fmt.Errorf("[sky]: unknown key %q", key)
+		}
+	}
+	return nil
+}
+
+// loadSprites reads named ASCII-art blocks: a name on its own line, followed
+// by its rows, ended by a blank line or the end of the section.
+func (m *Map) loadSprites(lines []string) error {
+	name := ""
+	var rows [][]rune
+	rowWidth := -1
+
+	finish := func() error {
+		if name == "" {
+			return nil
+		}
+		if len(rows) == 0 {
+			return fmt.Errorf("[sprites]: %q has no rows", name)
+		}
+		m.Sprites[name] = rows
+		name, rows, rowWidth = "", nil, -1
+		return nil
+	}
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			if err := finish(); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == "" {
+			name = trimmed
+			continue
+		}
+		r := []rune(line)
+		if rowWidth == -1 {
+			rowWidth = len(r)
+		} else if len(r) != rowWidth {
+			return fmt.Errorf("[sprites]: %q has mismatched row widths (row %d is %d runes, expected %d)", name, len(rows), len(r), rowWidth)
+		}
+		rows = append(rows, r)
+	}
+	return finish()
+}
+
+// loadEntities reads `<sprite> <x> <y> <facing|paper> [angle]` rows,
+// rejecting a sprite name that [sprites] does not define (LoadMap loads
+// [sprites] first, whatever the section order in the file). Spawns keep the
+// sprite by name; the sprite itself is looked up in Map.Sprites later.
+func (m *Map) loadEntities(lines []string) error {
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 4 {
+			return fmt.Errorf("[entities]: %q: expected \"<sprite> <x> <y> <facing|paper> [angle]\"", line)
+		}
+		x, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return fmt.Errorf("[entities]: %q: bad x %q", line, fields[1])
+		}
+		y, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return fmt.Errorf("[entities]: %q: bad y %q", line, fields[2])
+		}
+		var mode SpriteMode
+		switch fields[3] {
+		case "facing":
+			mode = SpriteFacing
+		case "paper":
+			mode = SpritePaper
+		default:
+			return fmt.Errorf("[entities]: %q: unknown mode %q (want facing or paper)", line, fields[3])
+		}
+		angle := 0.0
+		if len(fields) >= 5 {
+			angle, err = strconv.ParseFloat(fields[4], 64)
+			if err != nil {
+				return fmt.Errorf("[entities]: %q: bad angle %q", line, fields[4])
+			}
+		}
+		if _, ok := m.Sprites[fields[0]]; !ok {
+			return fmt.Errorf("[entities]: %q: sprite %q is not defined in [sprites]", line, fields[0])
+		}
+		m.EntitySpawns = append(m.EntitySpawns, entitySpawn{Sprite: fields[0], X: x, Y: y, Mode: mode, Angle: angle})
+	}
+	return nil
+}
+
+// loadTextures reads `<char|"floor"|"ceiling"> = <path>` lines, resolving
+// relative texture paths against the map file's own directory, and binds
+// them onto Walls/FloorTexture/CeilTexture. A wall character not bound here
+// just keeps the untextured distance-shaded rune it already had.
+func (m *Map) loadTextures(lines []string, baseDir string) error {
+	kv, err := parseKV(lines)
+	if err != nil {
+		return fmt.Errorf("[textures]: %w", err)
+	}
+	m.Walls = TextureSet{}
+	for key, val := range kv {
+		texPath := val
+		if !filepath.IsAbs(texPath) {
+			texPath = filepath.Join(baseDir, texPath)
+		}
+		tex, err := loadTexture(texPath)
+		if err != nil {
+			return fmt.Errorf("[textures]: %s = %s: %w", key, val, err)
+		}
+		if key == "floor" {
+			m.FloorTexture = tex
+			continue
+		}
+		if key == "ceiling" {
+			m.CeilTexture = tex
+			continue
+		}
+		if len(key) != 1 {
+			return fmt.Errorf("[textures]: %q is not a single wall character, \"floor\", or \"ceiling\"", key)
+		}
+		m.Walls[key[0]] = tex
+	}
+	return nil
+}
+
+// parseKV parses a block of `key = value` lines, ignoring blank lines.
+func parseKV(lines []string) (map[string]string, error) {
+	kv := map[string]string{}
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		parts := strings.SplitN(trimmed, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%q is not a \"key = value\" line", line)
+		}
+		kv[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return kv, nil
+}
@@ -0,0 +1,384 @@
+package main
+
+import "math"
+
+// This file introduces the portal/sector renderer that replaces the old
+// fixed-height grid raycaster, modelled on the classic sector engines (see
+// e.g. prender_win32.c): the world is a graph of convex sectors joined by
+// portals instead of a uniform grid of wall cells.
+
+// A vertex is a 2D point in the map's coordinate system, used as a corner of
+// a sector's polygon.
+type vertex struct {
+	x, y float64
+}
+
+// A plane describes a sloped floor or ceiling in the form z = a*x + b*y + c,
+// following the convention used by SRB2's slope patch. A nil *plane means
+// "flat at the sector's floor/ceil height".
+type plane struct {
+	a, b, c float64
+}
+
+// PGetZAt returns the height plane p contributes at map coordinate (x, y).
+// Callers add this to the sector's base floor/ceil height to get the actual
+// world height at that point; a nil plane contributes 0, i.e. flat.
+func PGetZAt(p *plane, x, y float64) float64 {
+	if p == nil {
+		return 0
+	}
+	return p.a*x + p.b*y + p.c
+}
+
+// A neighbor describes what lies beyond one edge of a sector: another sector
+// reachable through a portal (to >= 0), or a solid wall (to == -1).
+type neighbor struct {
+	to int
+}
+
+// A sector is a convex polygon of vertices with a floor and ceiling height,
+// plus optional slopes. Edge i runs from vertices[i] to
+// vertices[(i+1)%len(vertices)]; neighbors[i] describes what's across it.
+type sector struct {
+	vertices  []vertex
+	neighbors []neighbor
+	floor     float64
+	ceil      float64
+	fSlope    *plane // nil for a flat floor
+	cSlope    *plane // nil for a flat ceiling
+}
+
+// floorAt/ceilAt return the world height of the sector's floor/ceiling at a
+// given map coordinate, accounting for slope.
+func (s *sector) floorAt(x, y float64) float64 { return s.floor + PGetZAt(s.fSlope, x, y) }
+func (s *sector) ceilAt(x, y float64) float64  { return s.ceil + PGetZAt(s.cSlope, x, y) }
+
+// pointInSector reports whether (x, y) lies within the sector's polygon,
+// using the standard even-odd ray casting test.
+func (s *sector) pointInSector(x, y float64) bool {
+	in := false
+	n := len(s.vertices)
+	for i, j := 0, n-1; i < n; j, i = i, i+1 {
+		vi, vj := s.vertices[i], s.vertices[j]
+		if (vi.y > y) != (vj.y > y) &&
+			x < (vj.x-vi.x)*(y-vi.y)/(vj.y-vi.y)+vi.x {
+			in = !in
+		}
+	}
+	return in
+}
+
+// findSector returns the index of the sector containing (x, y), searching
+// from hint first (almost always still correct frame-to-frame) before
+// falling back to a scan of the whole world. Returns -1 if the point isn't
+// inside any sector.
+func findSector(world []sector, hint int, x, y float64) int {
+	if hint >= 0 && hint < len(world) && world[hint].pointInSector(x, y) {
+		return hint
+	}
+	for i := range world {
+		if world[i].pointInSector(x, y) {
+			return i
+		}
+	}
+	return -1
+}
+
+// fHFOV/fVFOV play the same role fFOV played for the old raycaster: they
+// control how much of the world is visible horizontally/vertically, but now
+// feed a polygon projection instead of a per-column ray march.
+const fHFOV = pi / 3.0
+const fVFOV = 1.2
+
+// EyeHeight/DuckHeight/KneeHeight describe the player's vertical profile: how
+// high their eye sits above the floor they're standing on (normal/ducking),
+// and how tall a ledge they can step up onto without jumping.
+const fEyeHeight = 0.9
+const fDuckHeight = 0.4
+const fKneeHeight = 0.4
+const fGravity = 12.0
+
+// intersectRay finds where the ray from (px, py) in direction (dx, dy)
+// crosses the segment v1->v2, returning the distance along the ray (t >= 0
+// for a hit in front of the player) and whether it actually hits within the
+// segment's bounds.
+func intersectRay(px, py, dx, dy float64, v1, v2 vertex) (t float64, hit bool) {
+	ex, ey := v2.x-v1.x, v2.y-v1.y
+	denom := dx*ey - dy*ex
+	if math.Abs(denom) < 1e-9 {
+		return 0, false // parallel
+	}
+	// Solve px + t*dx = v1.x + u*ex, py + t*dy = v1.y + u*ey for t, u.
+	u := ((px-v1.x)*dy - (py-v1.y)*dx) / (dy*ex - dx*ey)
+	if u < 0 || u > 1 {
+		return 0, false
+	}
+	if math.Abs(dx) > math.Abs(dy) {
+		t = (v1.x + u*ex - px) / dx
+	} else {
+		t = (v1.y + u*ey - py) / dy
+	}
+	return t, t > 0.0001
+}
+
+// screenYFor projects a world height h, seen at distance d from the player
+// whose eye sits at eyeZ, onto a screen row.
+func screenYFor(nScreenHeight int, h, d, eyeZ float64) float64 {
+	return float64(nScreenHeight)/2.0 - (h-eyeZ)/d*(float64(nScreenHeight)/fVFOV)
+}
+
+// portalEntry is one pending (sector, column range) job in the BFS queue
+// used by renderSectors to avoid drawing a column more than once.
+type portalEntry struct {
+	sec    int
+	x1, x2 int
+	depth  int
+}
+
+// renderSectors walks the sector graph breadth-first starting from the
+// sector the player occupies, projecting each sector's edges onto whatever
+// portion of the screen is still unobstructed (tracked per column via
+// ymin/ymax) and recursing through portals clipped to the resulting range.
+// Solid edges fill the column between projected ceiling and floor; portal
+// edges draw upper/lower "step" strips for any ceil/floor mismatch and then
+// enqueue the neighbor for the clipped column range.
+//
+// The returned ymin/ymax are what's left unobstructed per column once every
+// reachable sector has been drawn; the caller fills those with sky above and
+// floor pattern below, same as the old raycaster did above nCeiling/below
+// nFloor. zbuf holds, per column, the distance to the nearest wall drawn
+// there (or fDepth if no wall was hit), so entities can be clipped against
+// it afterwards.
+func renderSectors(world []sector, playerSector int, nScreenWidth, nScreenHeight int) (ymin, ymax, zbuf []float64) {
+	ymin = make([]float64, nScreenWidth) // top of the still-open span, per column
+	ymax = make([]float64, nScreenWidth) // bottom of the still-open span, per column
+	zbuf = make([]float64, nScreenWidth)
+	// closed tracks which columns have hit a solid wall and must not be
+	// drawn into again by a farther sector; ymin/ymax can't double as that
+	// flag once a solid hit narrows them to the wall's own [top, bot] for
+	// the caller's sky/floor fill.
+	closed := make([]bool, nScreenWidth)
+	for x := range ymin {
+		ymin[x] = 0
+		ymax[x] = float64(nScreenHeight)
+		zbuf[x] = fDepth
+	}
+
+	eyeZ := world[playerSector].floorAt(fPlayerX, fPlayerY) + fPlayerHeightOffset + fPlayerEyeZ
+
+	queue := []portalEntry{{sec: playerSector, x1: 0, x2: nScreenWidth - 1}}
+	for len(queue) > 0 {
+		job := queue[0]
+		queue = queue[1:]
+		if job.depth > 32 || job.x1 > job.x2 {
+			continue // guard against portal cycles chewing up the frame
+		}
+		sec := &world[job.sec]
+
+		for i, v1 := range sec.vertices {
+			v2 := sec.vertices[(i+1)%len(sec.vertices)]
+			nb := sec.neighbors[i].to
+
+			a1 := relativeAngle(v1)
+			a2 := relativeAngle(v2)
+			// Roughly stand in for DDA's X-side/Y-side distinction on an
+			// arbitrary polygon edge: one spanning more in x than y reads
+			// as a "horizontal" wall face, which ddaCast would call a
+			// Y-side hit.
+			sideIsY := math.Abs(v2.x-v1.x) > math.Abs(v2.y-v1.y)
+			cx1, cx2 := angleSpanToColumns(a1, a2, nScreenWidth)
+			if cx2 < job.x1 || cx1 > job.x2 || cx1 > cx2 {
+				continue // edge doesn't touch this job's column range
+			}
+			if cx1 < job.x1 {
+				cx1 = job.x1
+			}
+			if cx2 > job.x2 {
+				cx2 = job.x2
+			}
+
+			portalX1, portalX2 := nScreenWidth, -1 // clipped range actually opened by this portal
+			for x := cx1; x <= cx2; x++ {
+				if closed[x] {
+					continue // column already fully closed off
+				}
+				rayA := fPlayerA - fHFOV/2.0 + (float64(x)+0.5)/float64(nScreenWidth)*fHFOV
+				eyeX, eyeY := math.Sin(rayA), math.Cos(rayA)
+				dist, hit := intersectRay(fPlayerX, fPlayerY, eyeX, eyeY, v1, v2)
+				if !hit {
+					continue
+				}
+				wx, wy := fPlayerX+eyeX*dist, fPlayerY+eyeY*dist
+
+				top := clamp(screenYFor(nScreenHeight, sec.ceilAt(wx, wy), dist, eyeZ), ymin[x], ymax[x])
+				bot := clamp(screenYFor(nScreenHeight, sec.floorAt(wx, wy), dist, eyeZ), ymin[x], ymax[x])
+				if nb < 0 {
+					fillSpan(x, top, bot, wallShadeRune(dist, sideIsY))
+					if dist < zbuf[x] {
+						zbuf[x] = dist
+					}
+					// Column closed: only the strip above top/below bot is
+					// still open, for the caller's sky/floor fill.
+					ymin[x], ymax[x] = top, bot
+					closed[x] = true
+					continue
+				}
+
+				// Portal: draw step strips for any ceil/floor mismatch, then
+				// narrow this column's open span to what the neighbor can
+				// still show through.
+				neighborSec := &world[nb]
+				nTop := clamp(screenYFor(nScreenHeight, neighborSec.ceilAt(wx, wy), dist, eyeZ), top, bot)
+				nBot := clamp(screenYFor(nScreenHeight, neighborSec.floorAt(wx, wy), dist, eyeZ), top, bot)
+				fillSpan(x, top, nTop, wallShadeRune(dist, sideIsY))
+				fillSpan(x, nBot, bot, wallShadeRune(dist, sideIsY))
+				ymin[x], ymax[x] = nTop, nBot
+				if x < portalX1 {
+					portalX1 = x
+				}
+				if x > portalX2 {
+					portalX2 = x
+				}
+			}
+
+			if nb >= 0 && portalX2 >= portalX1 {
+				queue = append(queue, portalEntry{sec: nb, x1: portalX1, x2: portalX2, depth: job.depth + 1})
+			}
+		}
+	}
+	return ymin, ymax, zbuf
+}
+
+// wallShadeRune mirrors the old raycaster's distance-based shading tiers,
+// stepped one tier darker for a sideIsY hit so walls facing a different axis
+// read as a distinct surface -- the same role the old per-column corner
+// dot-product test played, without needing corner sorting to get it.
+func wallShadeRune(dist float64, sideIsY bool) rune {
+	tier := ' '
+	switch {
+	case dist <= fDepth/3.0:
+		tier = '█'
+	case dist <= fDepth/2.0:
+		tier = '▓'
+	case dist <= fDepth/1.1:
+		tier = '░'
+	}
+	if !sideIsY {
+		return tier
+	}
+	switch tier {
+	case '█':
+		return '▓'
+	case '▓':
+		return '░'
+	default:
+		return ' '
+	}
+}
+
+// fillSpan draws rune r in mazeStyle down column x from row y0 to row y1
+// (exclusive of y1), both given as fractional screen rows.
+func fillSpan(x int, y0, y1 float64, r rune) {
+	for y := int(math.Ceil(y0)); float64(y) < y1; y++ {
+		screen.SetContent(x, y, r, nil, mazeStyle)
+	}
+}
+
+// relativeAngle returns v's angle as seen from the player, wrapped to
+// [-pi, pi) so it can be compared against the [-fHFOV/2, fHFOV/2] view cone.
+func relativeAngle(v vertex) float64 {
+	a := math.Atan2(v.x-fPlayerX, v.y-fPlayerY) - fPlayerA
+	return a - tau*math.Floor((a+pi)/tau)
+}
+
+// angleSpanToColumns maps a [a1, a2] angular span (as produced by
+// relativeAngle) onto a screen column range; the caller clips the result to
+// the visible screen and to whatever range it's currently recursing into.
+func angleSpanToColumns(a1, a2 float64, nScreenWidth int) (x1, x2 int) {
+	if a1 > a2 {
+		a1, a2 = a2, a1
+	}
+	toCol := func(a float64) int {
+		return int((a+fHFOV/2.0)/fHFOV*float64(nScreenWidth) + 0.5)
+	}
+	x1, x2 = toCol(a1), toCol(a2)
+	if x1 < 0 {
+		x1 = 0
+	}
+	if x2 >= nScreenWidth {
+		x2 = nScreenWidth - 1
+	}
+	return x1, x2
+}
+
+func clamp(v, lo, hi float64) float64 {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// movePlayer attempts to move the player by (dx, dy), sliding into whichever
+// sector the destination point falls in. Crossing into a sector costs a step
+// up/down of fPlayerHeightOffset (eased out by gravity) if the floor height
+// differs, but only up to fKneeHeight; and it's blocked outright if the
+// portal's clearance is too short to fit through, even ducking.
+func movePlayer(world []sector, dx, dy float64) {
+	newX, newY := fPlayerX+dx, fPlayerY+dy
+	dst := findSector(world, nPlayerSector, newX, newY)
+	if dst < 0 {
+		return // outside the map entirely
+	}
+
+	if dst != nPlayerSector {
+		cur := &world[nPlayerSector]
+		next := &world[dst]
+		curFloor := cur.floorAt(fPlayerX, fPlayerY)
+		nextFloor := next.floorAt(newX, newY)
+		clearance := math.Min(cur.ceilAt(fPlayerX, fPlayerY), next.ceilAt(newX, newY)) - math.Max(curFloor, nextFloor)
+
+		if clearance < fDuckHeight {
+			return // can't fit through even ducking
+		}
+		if nextFloor-curFloor > fKneeHeight {
+			return // ledge too tall to step up onto
+		}
+
+		// Preserve the player's current height above ground across the step
+		// so gravity eases the hop rather than teleporting the camera.
+		fPlayerHeightOffset += curFloor - nextFloor
+		nPlayerSector = dst
+	}
+
+	fPlayerX, fPlayerY = newX, newY
+}
+
+// newExampleWorld builds a small two-room map to exercise the portal
+// renderer: a tall entry room connected by a doorway to a shorter room with a
+// raised floor, so crossing the portal shows both a floor step and a ceiling
+// step.
+func newExampleWorld() []sector {
+	return []sector{
+		{ // sector 0: tall entry room
+			vertices: []vertex{{0, 0}, {10, 0}, {10, 4}, {6, 4}, {0, 4}},
+			neighbors: []neighbor{
+				{to: -1}, {to: -1}, {to: 1}, {to: -1}, {to: -1},
+			},
+			floor: 0.0,
+			ceil:  3.0,
+		},
+		{ // sector 1: raised, lower-ceilinged room beyond the doorway. The
+			// 0.3 step is within fKneeHeight so it's actually climbable on
+			// foot, unlike a full 1.0 rise would be.
+			vertices: []vertex{{6, 4}, {10, 4}, {10, 10}, {6, 10}},
+			neighbors: []neighbor{
+				{to: 0}, {to: -1}, {to: -1}, {to: -1},
+			},
+			floor: 0.3,
+			ceil:  2.0,
+		},
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// This file introduces a simple palette-mapped texture system: a texture is
+// a grid of runes loaded straight from an ASCII-art file, sampled by
+// nearest-neighbor at a fractional (u, v) coordinate. Color still comes from
+// mazeStyle, darkened per-pixel by distance via dimStyle, rather than from
+// the art file -- the "palette" is exactly the one fixed foreground color,
+// just dimmed, same as the untextured renderer's shading tiers.
+
+// texture is a 2D grid of runes, addressed by fractional (u, v) in [0, 1).
+type texture struct {
+	rows          [][]rune
+	width, height int
+}
+
+// loadTexture reads an ASCII-art file into a texture. Every line must be the
+// same rune width; blank lines are skipped.
+func loadTexture(path string) (*texture, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("texture: %w", err)
+	}
+	defer f.Close()
+
+	var rows [][]rune
+	width := -1
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		r := []rune(line)
+		if width == -1 {
+			width = len(r)
+		} else if len(r) != width {
+			return nil, fmt.Errorf("texture: %s: row %d has width %d, expected %d", path, len(rows), len(r), width)
+		}
+		rows = append(rows, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("texture: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("texture: %s: has no rows", path)
+	}
+	return &texture{rows: rows, width: width, height: len(rows)}, nil
+}
+
+// at nearest-neighbor samples the texture at fractional (u, v), each taken
+// mod 1 so a U/V that drifted outside [0, 1) (e.g. a floor coordinate far
+// from the origin) still wraps instead of panicking.
+func (t *texture) at(u, v float64) rune {
+	u -= math.Floor(u)
+	v -= math.Floor(v)
+	x := clampInt(int(u*float64(t.width)), 0, t.width-1)
+	y := clampInt(int(v*float64(t.height)), 0, t.height-1)
+	return t.rows[y][x]
+}
+
+// TextureSet maps a [map] wall character (currently only '#' is ever
+// produced by loadGrid) to the texture drawn on it.
+type TextureSet map[byte]*texture
+
+// dimStyle darkens style's foreground proportionally to dist/fDepth using
+// tcell's RGB constructor, replacing the old fixed mazeStyle with something
+// that still reads as farther away the deeper a textured surface is.
+func dimStyle(style tcell.Style, dist float64) tcell.Style {
+	_, fg, _ := style.Decompose()
+	r, g, b := fg.RGB()
+	k := 1.0 - clamp(dist/fDepth, 0, 1)
+	dim := func(c int32) int32 { return int32(float64(c) * k) }
+	return style.Foreground(tcell.NewRGBColor(dim(r), dim(g), dim(b)))
+}